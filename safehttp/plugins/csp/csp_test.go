@@ -0,0 +1,177 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-safeweb/safehttp"
+	"github.com/google/go-safeweb/safehttp/plugins/csp"
+	"github.com/google/go-safeweb/safehttp/plugins/staticheaders"
+	"github.com/google/safehtml"
+)
+
+type testDispatcher struct{}
+
+func (testDispatcher) Write(rw http.ResponseWriter, resp safehttp.Response) error {
+	switch x := resp.(type) {
+	case safehtml.HTML:
+		_, err := rw.Write([]byte(x.String()))
+		return err
+	default:
+		panic("not a safe response type")
+	}
+}
+
+func (testDispatcher) ExecuteTemplate(rw http.ResponseWriter, t safehttp.Template, data interface{}) error {
+	panic("unused")
+}
+
+type responseRecorder struct {
+	header http.Header
+	writer io.Writer
+	status int
+}
+
+func newResponseRecorder(w io.Writer) *responseRecorder {
+	return &responseRecorder{header: http.Header{}, writer: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) Header() http.Header { return r.header }
+
+func (r *responseRecorder) WriteHeader(statusCode int) { r.status = statusCode }
+
+func (r *responseRecorder) Write(data []byte) (int, error) { return r.writer.Write(data) }
+
+func handle(t *testing.T, p *csp.Plugin, req *http.Request) *responseRecorder {
+	t.Helper()
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	return rec
+}
+
+// nonceFromHeader extracts the script-src nonce from a rendered
+// Content-Security-Policy header value.
+func nonceFromHeader(t *testing.T, header string) string {
+	t.Helper()
+	if !strings.Contains(header, "script-src 'nonce-") {
+		t.Fatalf("Content-Security-Policy: got %q, want it to contain a script-src nonce", header)
+	}
+	start := strings.Index(header, "'nonce-") + len("'nonce-")
+	end := strings.Index(header[start:], "'") + start
+	return header[start:end]
+}
+
+func TestNonceUniquePerRequest(t *testing.T) {
+	p := csp.NewPlugin(csp.Config{})
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("GET", "https://foo.com/", nil)
+		rec := handle(t, p, req)
+		nonce := nonceFromHeader(t, rec.Header().Get("Content-Security-Policy"))
+		if seen[nonce] {
+			t.Fatalf("nonce %q reused across requests", nonce)
+		}
+		seen[nonce] = true
+	}
+}
+
+// TestNonceAvailableToHandlerViaContext proves that csp.Nonce can retrieve,
+// from the context of the *safehttp.IncomingRequest Before mutated, the same
+// nonce that ended up in the Content-Security-Policy header — the mechanism
+// safehtml/template callers rely on to render <script nonce="{{.Nonce}}">.
+func TestNonceAvailableToHandlerViaContext(t *testing.T) {
+	p := csp.NewPlugin(csp.Config{})
+	var gotNonce string
+	handler := func(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
+		result := p.Before(w, r)
+		// Simulates a handler downstream of the plugin in the same
+		// Machinery pipeline, reading the request's context the way a
+		// safehtml/template caller would.
+		gotNonce = csp.Nonce(r.Context())
+		return result
+	}
+
+	req := httptest.NewRequest("GET", "https://foo.com/", nil)
+	m := safehttp.NewMachinery(handler, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if gotNonce == "" {
+		t.Fatal("csp.Nonce(r.Context()) after Before: got empty, want the nonce set in the CSP header")
+	}
+	wantNonce := nonceFromHeader(t, rec.Header().Get("Content-Security-Policy"))
+	if gotNonce != wantNonce {
+		t.Errorf("csp.Nonce(r.Context()): got %q, want it to match the header's nonce %q", gotNonce, wantNonce)
+	}
+}
+
+func TestEnforcingMode(t *testing.T) {
+	p := csp.NewPlugin(csp.Config{ReportURI: "/csp-reports"})
+	req := httptest.NewRequest("GET", "https://foo.com/", nil)
+	rec := handle(t, p, req)
+
+	if got := rec.Header().Get("Content-Security-Policy"); got == "" {
+		t.Errorf("Content-Security-Policy: got empty, want non-empty")
+	}
+	if got := rec.Header().Get("Content-Security-Policy-Report-Only"); got != "" {
+		t.Errorf("Content-Security-Policy-Report-Only: got %q, want empty", got)
+	}
+}
+
+func TestReportOnlyMode(t *testing.T) {
+	p := csp.NewPlugin(csp.Config{ReportOnly: true, ReportURI: "/csp-reports"})
+	req := httptest.NewRequest("GET", "https://foo.com/", nil)
+	rec := handle(t, p, req)
+
+	if got := rec.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("Content-Security-Policy: got %q, want empty", got)
+	}
+	if got := rec.Header().Get("Content-Security-Policy-Report-Only"); got == "" {
+		t.Errorf("Content-Security-Policy-Report-Only: got empty, want non-empty")
+	}
+}
+
+func TestCoexistsWithStaticheaders(t *testing.T) {
+	cspPlugin := csp.NewPlugin(csp.Config{})
+	staticPlugin := staticheaders.Plugin{}
+	before := func(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
+		cspPlugin.Before(w, r)
+		return staticPlugin.Before(w, r)
+	}
+
+	req := httptest.NewRequest("GET", "https://foo.com/", nil)
+	m := safehttp.NewMachinery(before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if rec.status != http.StatusOK {
+		t.Fatalf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Security-Policy"); got == "" {
+		t.Errorf("Content-Security-Policy: got empty, want non-empty")
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options: got %q, want nosniff", got)
+	}
+}
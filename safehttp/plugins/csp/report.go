@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Report is a single CSP violation, in the shape the browser sends it
+// inside a legacy application/csp-report body (report-uri) or as the Body
+// of a ReportingReport (report-to).
+type Report struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	BlockedURI         string `json:"blocked-uri"`
+	StatusCode         int    `json:"status-code"`
+}
+
+type legacyReportBody struct {
+	CSPReport Report `json:"csp-report"`
+}
+
+// ParseReport parses the body of a legacy application/csp-report request,
+// as sent by browsers for a policy's report-uri directive.
+func ParseReport(body io.Reader) (Report, error) {
+	var b legacyReportBody
+	if err := json.NewDecoder(body).Decode(&b); err != nil {
+		return Report{}, fmt.Errorf("csp: couldn't parse csp-report body: %v", err)
+	}
+	return b.CSPReport, nil
+}
+
+// ReportingReport is a single entry of the newer Reporting API format, as
+// sent in an application/reports+json body for a policy's report-to
+// directive. A single request can batch reports for several endpoints.
+type ReportingReport struct {
+	Type string `json:"type"`
+	Age  int    `json:"age"`
+	URL  string `json:"url"`
+	Body Report `json:"body"`
+}
+
+// ParseReportingReports parses the body of an application/reports+json
+// request.
+func ParseReportingReports(body io.Reader) ([]ReportingReport, error) {
+	var reports []ReportingReport
+	if err := json.NewDecoder(body).Decode(&reports); err != nil {
+		return nil, fmt.Errorf("csp: couldn't parse reports+json body: %v", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-safeweb/safehttp/plugins/csp"
+)
+
+func TestParseReport(t *testing.T) {
+	body := `{
+		"csp-report": {
+			"document-uri": "https://foo.com/page",
+			"referrer": "",
+			"violated-directive": "script-src-elem",
+			"effective-directive": "script-src-elem",
+			"original-policy": "script-src 'nonce-abc'",
+			"blocked-uri": "https://evil.com/x.js",
+			"status-code": 200
+		}
+	}`
+	got, err := csp.ParseReport(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("ParseReport: got %v, want nil", err)
+	}
+	want := csp.Report{
+		DocumentURI:        "https://foo.com/page",
+		ViolatedDirective:  "script-src-elem",
+		EffectiveDirective: "script-src-elem",
+		OriginalPolicy:     "script-src 'nonce-abc'",
+		BlockedURI:         "https://evil.com/x.js",
+		StatusCode:         200,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseReport mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseReportingReports(t *testing.T) {
+	body := `[{
+		"type": "csp-violation",
+		"age": 10,
+		"url": "https://foo.com/page",
+		"body": {
+			"document-uri": "https://foo.com/page",
+			"blocked-uri": "https://evil.com/x.js"
+		}
+	}]`
+	got, err := csp.ParseReportingReports(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("ParseReportingReports: got %v, want nil", err)
+	}
+	want := []csp.ReportingReport{
+		{
+			Type: "csp-violation",
+			Age:  10,
+			URL:  "https://foo.com/page",
+			Body: csp.Report{
+				DocumentURI: "https://foo.com/page",
+				BlockedURI:  "https://evil.com/x.js",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseReportingReports mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseReportMalformed(t *testing.T) {
+	if _, err := csp.ParseReport(strings.NewReader("not json")); err == nil {
+		t.Error("ParseReport: got nil error, want non-nil")
+	}
+}
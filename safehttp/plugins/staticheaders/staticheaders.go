@@ -15,27 +15,152 @@
 package staticheaders
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/google/go-safeweb/safehttp"
 )
 
-// Plugin claims and sets static headers on responses.
-type Plugin struct{}
+// HSTSConfig configures the Strict-Transport-Security header. It's only
+// sent on requests served over TLS, unless Force is set.
+type HSTSConfig struct {
+	// MaxAge is how long browsers should remember to only access this site
+	// over HTTPS.
+	MaxAge time.Duration
+	// IncludeSubdomains, if true, applies the policy to all subdomains too.
+	IncludeSubdomains bool
+	// Preload, if true, marks the site as eligible for inclusion in
+	// browsers' built-in HSTS preload lists.
+	Preload bool
+	// Force sends the header even on plaintext requests. This is normally
+	// pointless, since the header is only honored over a response that was
+	// itself received over HTTPS, but it can be useful behind a TLS-
+	// terminating proxy that talks plaintext HTTP to the backend.
+	Force bool
+}
+
+// Plugin claims and sets a configurable set of security-related response
+// headers. Leaving a field at its zero value skips the corresponding
+// header.
+type Plugin struct {
+	// HSTS, if non-nil, sets Strict-Transport-Security.
+	HSTS *HSTSConfig
+	// ReferrerPolicy sets the Referrer-Policy header, e.g.
+	// "strict-origin-when-cross-origin".
+	ReferrerPolicy string
+	// PermissionsPolicy sets the Permissions-Policy header, e.g.
+	// "geolocation=(), microphone=()".
+	PermissionsPolicy string
+	// COOP sets the Cross-Origin-Opener-Policy header, e.g. "same-origin".
+	COOP string
+	// COEP sets the Cross-Origin-Embedder-Policy header, e.g.
+	// "require-corp".
+	COEP string
+	// CORP sets the Cross-Origin-Resource-Policy header, e.g.
+	// "same-origin".
+	CORP string
+}
+
+// Recommended returns a Plugin configured with a set of defaults that are
+// safe for most applications to adopt without breaking legitimate
+// cross-origin use cases.
+func Recommended() Plugin {
+	return Plugin{
+		HSTS: &HSTSConfig{
+			MaxAge:            2 * 365 * 24 * time.Hour,
+			IncludeSubdomains: true,
+		},
+		ReferrerPolicy:    "strict-origin-when-cross-origin",
+		PermissionsPolicy: "geolocation=(), microphone=(), camera=()",
+		COOP:              "same-origin",
+		CORP:              "same-origin",
+	}
+}
 
-// Before claims and sets the following headers:
+// Strict returns a Plugin configured with the strictest defaults, suitable
+// for applications that neither embed nor expect to be embedded by
+// third-party origins.
+func Strict() Plugin {
+	p := Recommended()
+	p.HSTS.Preload = true
+	p.ReferrerPolicy = "no-referrer"
+	p.PermissionsPolicy = "geolocation=(), microphone=(), camera=(), payment=(), usb=()"
+	p.COEP = "require-corp"
+	return p
+}
+
+func hstsValue(c *HSTSConfig) string {
+	v := fmt.Sprintf("max-age=%d", int(c.MaxAge.Seconds()))
+	if c.IncludeSubdomains {
+		v += "; includeSubDomains"
+	}
+	if c.Preload {
+		v += "; preload"
+	}
+	return v
+}
+
+// claim claims name through h and sets it to value, returning the Claim
+// error, if any, unwrapped so callers can turn it into a 500.
+func claim(h safehttp.Header, name, value string) error {
+	set, err := h.Claim(name)
+	if err != nil {
+		return err
+	}
+	set([]string{value})
+	return nil
+}
+
+// Before claims and sets the following headers, skipping any whose
+// corresponding field is left at its zero value:
 //  - X-Content-Type-Options: nosniff
 //  - X-XSS-Protection: 0
-func (Plugin) Before(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
+//  - Strict-Transport-Security (HSTS)
+//  - Referrer-Policy
+//  - Permissions-Policy
+//  - Cross-Origin-Opener-Policy (COOP)
+//  - Cross-Origin-Embedder-Policy (COEP)
+//  - Cross-Origin-Resource-Policy (CORP)
+func (p Plugin) Before(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
 	h := w.Header()
-	setXCTO, err := h.Claim("X-Content-Type-Options")
-	if err != nil {
+
+	if err := claim(h, "X-Content-Type-Options", "nosniff"); err != nil {
 		return w.ServerError(safehttp.StatusInternalServerError)
 	}
-	setXCTO([]string{"nosniff"})
-
-	setXXP, err := h.Claim("X-XSS-Protection")
-	if err != nil {
+	if err := claim(h, "X-XSS-Protection", "0"); err != nil {
 		return w.ServerError(safehttp.StatusInternalServerError)
 	}
-	setXXP([]string{"0"})
+
+	if p.HSTS != nil && (r.TLS != nil || p.HSTS.Force) {
+		if err := claim(h, "Strict-Transport-Security", hstsValue(p.HSTS)); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+	}
+	if p.ReferrerPolicy != "" {
+		if err := claim(h, "Referrer-Policy", p.ReferrerPolicy); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+	}
+	if p.PermissionsPolicy != "" {
+		if err := claim(h, "Permissions-Policy", p.PermissionsPolicy); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+	}
+	if p.COOP != "" {
+		if err := claim(h, "Cross-Origin-Opener-Policy", p.COOP); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+	}
+	if p.COEP != "" {
+		if err := claim(h, "Cross-Origin-Embedder-Policy", p.COEP); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+	}
+	if p.CORP != "" {
+		if err := claim(h, "Cross-Origin-Resource-Policy", p.CORP); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+	}
+
 	return safehttp.Result{}
 }
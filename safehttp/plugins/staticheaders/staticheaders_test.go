@@ -0,0 +1,136 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package staticheaders_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-safeweb/safehttp"
+	"github.com/google/go-safeweb/safehttp/plugins/staticheaders"
+	"github.com/google/safehtml"
+)
+
+type testDispatcher struct{}
+
+func (testDispatcher) Write(rw http.ResponseWriter, resp safehttp.Response) error {
+	switch x := resp.(type) {
+	case safehtml.HTML:
+		_, err := rw.Write([]byte(x.String()))
+		return err
+	default:
+		panic("not a safe response type")
+	}
+}
+
+func (testDispatcher) ExecuteTemplate(rw http.ResponseWriter, t safehttp.Template, data interface{}) error {
+	panic("unused")
+}
+
+type responseRecorder struct {
+	header http.Header
+	writer io.Writer
+	status int
+}
+
+func newResponseRecorder(w io.Writer) *responseRecorder {
+	return &responseRecorder{header: http.Header{}, writer: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) Header() http.Header { return r.header }
+
+func (r *responseRecorder) WriteHeader(statusCode int) { r.status = statusCode }
+
+func (r *responseRecorder) Write(data []byte) (int, error) { return r.writer.Write(data) }
+
+func TestRecommendedSetsConfiguredHeaders(t *testing.T) {
+	p := staticheaders.Recommended()
+	req := httptest.NewRequest("GET", "https://foo.com/", nil)
+	req.TLS = &tls.ConnectionState{}
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if rec.status != http.StatusOK {
+		t.Fatalf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+	want := map[string][]string{
+		"X-Content-Type-Options":       {"nosniff"},
+		"X-Xss-Protection":             {"0"},
+		"Strict-Transport-Security":    {"max-age=63072000; includeSubDomains"},
+		"Referrer-Policy":              {"strict-origin-when-cross-origin"},
+		"Permissions-Policy":           {"geolocation=(), microphone=(), camera=()"},
+		"Cross-Origin-Opener-Policy":   {"same-origin"},
+		"Cross-Origin-Resource-Policy": {"same-origin"},
+	}
+	if diff := cmp.Diff(want, map[string][]string(rec.Header())); diff != "" {
+		t.Errorf("rec.Header() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHSTSSkippedOnPlaintext(t *testing.T) {
+	p := staticheaders.Recommended()
+	req := httptest.NewRequest("GET", "http://foo.com/", nil)
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security on plaintext request: got %q, want empty", got)
+	}
+}
+
+func TestHSTSForcedOnPlaintext(t *testing.T) {
+	p := staticheaders.Recommended()
+	p.HSTS.Force = true
+	req := httptest.NewRequest("GET", "http://foo.com/", nil)
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if got := rec.Header().Get("Strict-Transport-Security"); got == "" {
+		t.Errorf("Strict-Transport-Security with Force: got empty, want non-empty")
+	}
+}
+
+func TestHeaderCollisionWithAnotherClaimant(t *testing.T) {
+	p := staticheaders.Plugin{}
+	before := func(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
+		p.Before(w, r)
+		// A second plugin claiming a header staticheaders already claimed
+		// should be rejected.
+		if _, err := w.Header().Claim("X-Content-Type-Options"); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+		return safehttp.Result{}
+	}
+	req := httptest.NewRequest("GET", "https://foo.com/", nil)
+	m := safehttp.NewMachinery(before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if rec.status != http.StatusInternalServerError {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/google/go-safeweb/safehttp"
+)
+
+// DoubleSubmitCookieName is the cookie the DoubleSubmitPlugin uses to carry
+// its token. It's prefixed with __Host- so browsers refuse to accept it
+// unless it's Secure, has Path=/ and carries no Domain attribute, which
+// rules out a whole class of cookie-injection attacks from sibling domains.
+const DoubleSubmitCookieName = "__Host-XSRF"
+
+// DoubleSubmitHeaderName is the request header the DoubleSubmitPlugin reads
+// the token from, for callers that can set custom headers (e.g. SPAs making
+// XHR/fetch requests) rather than submitting a form.
+const DoubleSubmitHeaderName = "X-XSRF-Token"
+
+// DoubleSubmitPlugin implements the double-submit cookie pattern: it has no
+// server-side state and doesn't need a UserIDStorage or a shared HMAC key,
+// which makes it suitable for stateless APIs and SPAs that xsrf.Plugin can't
+// serve because they can't supply a stable user ID or a form/multipart body.
+//
+// On safe requests it sets a random token in the DoubleSubmitCookieName
+// cookie. On unsafe requests it checks that the same token is echoed back
+// in either the DoubleSubmitHeaderName header or the TokenKey form field;
+// since an attacker's page can make the browser send the cookie but can't
+// read it to copy its value into the header or body, this proves the
+// request originated from a page that can read the cookie, i.e. same-origin.
+type DoubleSubmitPlugin struct{}
+
+// NewDoubleSubmitPlugin creates a new DoubleSubmitPlugin.
+func NewDoubleSubmitPlugin() *DoubleSubmitPlugin {
+	return &DoubleSubmitPlugin{}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("xsrf: couldn't generate random token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// Before, on safe requests, issues a fresh DoubleSubmitCookieName cookie
+// through w.SetCookie. On unsafe requests it instead validates
+// that the cookie's value is echoed back in the DoubleSubmitHeaderName
+// header or the TokenKey form field.
+func (p *DoubleSubmitPlugin) Before(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
+	if isSafeMethod(r.Method) {
+		tok, err := randomToken()
+		if err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+		cookie := safehttp.NewCookie(DoubleSubmitCookieName, tok)
+		cookie.SetPath("/")
+		cookie.SetSecure(true)
+		cookie.SetSameSite(safehttp.SameSiteStrictMode)
+		if err := w.SetCookie(cookie); err != nil {
+			return w.ServerError(safehttp.StatusInternalServerError)
+		}
+		return safehttp.Result{}
+	}
+
+	cookie, err := r.Cookie(DoubleSubmitCookieName)
+	if err != nil {
+		return w.ClientError(safehttp.StatusUnauthorized)
+	}
+
+	tok := r.Header.Get(DoubleSubmitHeaderName)
+	if tok == "" {
+		tok = r.PostFormValue(TokenKey)
+	}
+	if tok == "" {
+		return w.ClientError(safehttp.StatusUnauthorized)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(tok), []byte(cookie.Value)) != 1 {
+		return w.ClientError(safehttp.StatusForbidden)
+	}
+	return safehttp.Result{}
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsrf_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-safeweb/safehttp"
+	"github.com/google/go-safeweb/safehttp/plugins/xsrf"
+)
+
+func TestDoubleSubmitSetsCookieOnSafeRequest(t *testing.T) {
+	p := xsrf.NewDoubleSubmitPlugin()
+	req := httptest.NewRequest("GET", "http://foo.com/pizza", nil)
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+	setCookie := rec.Header()["Set-Cookie"]
+	if len(setCookie) != 1 {
+		t.Fatalf("Set-Cookie header: got %v, want exactly one", setCookie)
+	}
+	parsed := (&http.Response{Header: http.Header{"Set-Cookie": setCookie}}).Cookies()
+	if len(parsed) != 1 {
+		t.Fatalf("issued cookies: got %d, want 1", len(parsed))
+	}
+	c := parsed[0]
+	if c.Name != xsrf.DoubleSubmitCookieName {
+		t.Errorf("cookie name: got %q, want %q", c.Name, xsrf.DoubleSubmitCookieName)
+	}
+	// These attributes are what makes the __Host- prefix protection hold:
+	// without them, a network attacker or a sibling domain could plant a
+	// cookie of the same name and defeat the double-submit check.
+	if !c.Secure {
+		t.Error("cookie Secure: got false, want true")
+	}
+	if c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie SameSite: got %v, want %v", c.SameSite, http.SameSiteStrictMode)
+	}
+	if c.Path != "/" {
+		t.Errorf("cookie Path: got %q, want %q", c.Path, "/")
+	}
+}
+
+func issueDoubleSubmitCookie(t *testing.T) string {
+	t.Helper()
+	p := xsrf.NewDoubleSubmitPlugin()
+	req := httptest.NewRequest("GET", "http://foo.com/pizza", nil)
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	setCookie := rec.Header().Get("Set-Cookie")
+	parsed := (&http.Response{Header: http.Header{"Set-Cookie": {setCookie}}}).Cookies()
+	if len(parsed) != 1 {
+		t.Fatalf("issued cookies: got %d, want 1", len(parsed))
+	}
+	return parsed[0].Value
+}
+
+func TestDoubleSubmitHeaderVerification(t *testing.T) {
+	tok := issueDoubleSubmitCookie(t)
+	p := xsrf.NewDoubleSubmitPlugin()
+
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", nil)
+	req.AddCookie(&http.Cookie{Name: xsrf.DoubleSubmitCookieName, Value: tok})
+	req.Header.Set(xsrf.DoubleSubmitHeaderName, tok)
+
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+}
+
+func TestDoubleSubmitFormFieldVerification(t *testing.T) {
+	tok := issueDoubleSubmitCookie(t)
+	p := xsrf.NewDoubleSubmitPlugin()
+
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", strings.NewReader(xsrf.TokenKey+"="+tok))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: xsrf.DoubleSubmitCookieName, Value: tok})
+
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+}
+
+func TestDoubleSubmitCookieHeaderMismatch(t *testing.T) {
+	tok := issueDoubleSubmitCookie(t)
+	p := xsrf.NewDoubleSubmitPlugin()
+
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", nil)
+	req.AddCookie(&http.Cookie{Name: xsrf.DoubleSubmitCookieName, Value: tok})
+	req.Header.Set(xsrf.DoubleSubmitHeaderName, "not-the-right-token")
+
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusForbidden {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusForbidden)
+	}
+}
+
+func TestDoubleSubmitMissingCookie(t *testing.T) {
+	p := xsrf.NewDoubleSubmitPlugin()
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", nil)
+	req.Header.Set(xsrf.DoubleSubmitHeaderName, "whatever")
+
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusUnauthorized {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusUnauthorized)
+	}
+}
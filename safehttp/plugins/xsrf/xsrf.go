@@ -0,0 +1,303 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xsrf provides a plugin that mitigates Cross-Site Request Forgery
+// attacks by requiring a per-user, per-resource token on state-changing
+// requests.
+package xsrf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	stdpath "path"
+	"time"
+
+	"github.com/google/go-safeweb/safehttp"
+)
+
+// TokenKey is the name of the form field that carries the XSRF token on
+// POST and multipart/form-data requests.
+const TokenKey = "xsrf-token"
+
+// allowedClockSkew bounds how far in the future a token's embedded
+// timestamp may sit before it's rejected, to tolerate clock drift between
+// the host that issued the token and the host validating it.
+const allowedClockSkew = 1 * time.Minute
+
+// now is a seam over time.Now so tests can simulate clock skew.
+var now = time.Now
+
+var (
+	errMalformedToken = errors.New("xsrf: malformed token")
+	errExpiredToken   = errors.New("xsrf: token expired")
+)
+
+// UserIDStorage is used to fetch the ID of the user issuing the current
+// request, which is mixed into the generated token.
+type UserIDStorage interface {
+	GetUserID() (string, error)
+}
+
+// Option configures a Plugin constructed with NewPlugin.
+type Option func(*Plugin)
+
+// TokenTTL makes generated tokens expire after the given duration. The
+// default, when this option isn't supplied, is that tokens never expire.
+func TokenTTL(ttl time.Duration) Option {
+	return func(p *Plugin) { p.tokenTTL = ttl }
+}
+
+// PreviousSecrets registers secrets that are no longer used to generate new
+// tokens but are still accepted when validating incoming ones. This allows
+// the current secret to be rotated without invalidating every token that's
+// already been handed out.
+func PreviousSecrets(secrets ...string) Option {
+	return func(p *Plugin) { p.previousSecrets = secrets }
+}
+
+// AllowedOrigins registers additional hosts, beyond the request's own Host,
+// that are trusted as the Origin or Referer of a state-changing request.
+func AllowedOrigins(hosts ...string) Option {
+	return func(p *Plugin) { p.allowedOrigins = append(p.allowedOrigins, hosts...) }
+}
+
+type exemption struct {
+	method      string
+	pathPattern string
+}
+
+// Plugin generates and validates XSRF tokens to protect against
+// Cross-Site Request Forgery attacks.
+type Plugin struct {
+	secret          string
+	previousSecrets []string
+	storage         UserIDStorage
+	tokenTTL        time.Duration
+	allowedOrigins  []string
+	exemptions      []exemption
+	exemptFuncs     []func(*safehttp.IncomingRequest) bool
+}
+
+// NewPlugin creates a new XSRF Plugin. secret is used both to generate new
+// tokens and to validate incoming ones; storage is consulted for the ID of
+// the user the token is bound to.
+//
+// Use TokenTTL to make tokens expire and PreviousSecrets to keep accepting
+// tokens signed with a secret that's being rotated out.
+func NewPlugin(secret string, storage UserIDStorage, opts ...Option) *Plugin {
+	p := &Plugin{secret: secret, storage: storage}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+// Exempt carves pathPattern (matched with path.Match) and method (or "*"
+// for any method) out of XSRF validation, for endpoints that are
+// intentionally invoked cross-origin, such as webhooks or OAuth callbacks.
+func (p *Plugin) Exempt(method, pathPattern string) {
+	p.exemptions = append(p.exemptions, exemption{method: method, pathPattern: pathPattern})
+}
+
+// ExemptFunc carves out of XSRF validation any request for which f returns
+// true.
+func (p *Plugin) ExemptFunc(f func(*safehttp.IncomingRequest) bool) {
+	p.exemptFuncs = append(p.exemptFuncs, f)
+}
+
+func (p *Plugin) exempt(r *safehttp.IncomingRequest) bool {
+	for _, e := range p.exemptions {
+		if e.method != "*" && e.method != r.Method {
+			continue
+		}
+		if ok, _ := stdpath.Match(e.pathPattern, r.URL.Path); ok {
+			return true
+		}
+	}
+	for _, f := range p.exemptFuncs {
+		if f(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// originAllowed reports whether the Origin (or, failing that, Referer)
+// header of a state-changing request matches the request's own host or one
+// of the hosts registered with AllowedOrigins. Requests that carry neither
+// header are allowed through, since this check is a defense-in-depth layer
+// on top of, not a replacement for, token validation. A header that is
+// present but carries no usable host — including the literal "null" that
+// browsers send for opaque origins such as sandboxed iframes or data: URLs —
+// is rejected rather than treated as absent.
+func (p *Plugin) originAllowed(r *safehttp.IncomingRequest) bool {
+	present := false
+	origin := ""
+	if h := r.Header.Get("Origin"); h != "" {
+		present = true
+		if u, err := url.Parse(h); err == nil {
+			origin = u.Host
+		}
+	} else if h := r.Header.Get("Referer"); h != "" {
+		present = true
+		if u, err := url.Parse(h); err == nil {
+			origin = u.Host
+		}
+	}
+	if !present {
+		return true
+	}
+	if origin == "" {
+		return false
+	}
+	if origin == r.Host {
+		return true
+	}
+	for _, allowed := range p.allowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// writeField writes s to mac preceded by its length as a 4-byte big-endian
+// prefix, so that concatenating variable-length fields without a delimiter
+// can't let different (host, path, userID) splits hash to the same MAC
+// (e.g. host="foo.com", path="/pizza" colliding with
+// host="foo.com/piz", path="za").
+func writeField(mac hash.Hash, s string) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(s)))
+	mac.Write(l[:])
+	mac.Write([]byte(s))
+}
+
+// computeMAC returns the HMAC-SHA256 of host, path, userID and issuedAt
+// under secret.
+func computeMAC(secret, host, path, userID string, issuedAt int64) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	writeField(mac, host)
+	writeField(mac, path)
+	writeField(mac, userID)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(issuedAt))
+	mac.Write(ts[:])
+	return mac.Sum(nil)
+}
+
+// GenerateToken generates an XSRF token bound to host, path and the current
+// user, as reported by the Plugin's UserIDStorage. The token embeds its
+// issuance time so Before can reject it once it's older than TokenTTL.
+func (p *Plugin) GenerateToken(host, path string) (string, error) {
+	userID, err := p.storage.GetUserID()
+	if err != nil {
+		return "", fmt.Errorf("xsrf: couldn't generate token: %v", err)
+	}
+	issuedAt := now().Unix()
+	mac := computeMAC(p.secret, host, path, userID, issuedAt)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(issuedAt))
+	return base64.RawURLEncoding.EncodeToString(ts[:]) + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// validToken reports whether tok is a well-formed, unexpired token for
+// host, path and userID. Every configured secret (the current one and any
+// PreviousSecrets) is tried, in constant time, so that a key rotation in
+// progress doesn't reject tokens signed with the secret being phased out.
+func (p *Plugin) validToken(tok, host, path, userID string) (bool, error) {
+	dot := -1
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '.' {
+			dot = i
+			break
+		}
+	}
+	if dot < 0 {
+		return false, errMalformedToken
+	}
+	tsb, err := base64.RawURLEncoding.DecodeString(tok[:dot])
+	if err != nil || len(tsb) != 8 {
+		return false, errMalformedToken
+	}
+	gotMAC, err := base64.RawURLEncoding.DecodeString(tok[dot+1:])
+	if err != nil {
+		return false, errMalformedToken
+	}
+
+	issuedAt := int64(binary.BigEndian.Uint64(tsb))
+	age := now().Sub(time.Unix(issuedAt, 0))
+	if p.tokenTTL > 0 && age > p.tokenTTL {
+		return false, errExpiredToken
+	}
+	if age < -allowedClockSkew {
+		return false, errExpiredToken
+	}
+
+	secrets := append([]string{p.secret}, p.previousSecrets...)
+	valid := 0
+	for _, secret := range secrets {
+		want := computeMAC(secret, host, path, userID, issuedAt)
+		valid |= subtle.ConstantTimeCompare(want, gotMAC)
+	}
+	return valid == 1, nil
+}
+
+// Before claims the X-Content-Type-Options header, then validates the
+// request. RFC 7231 safe methods (GET, HEAD, OPTIONS, TRACE) and requests
+// matching an Exempt/ExemptFunc rule pass without a token. Everything else
+// must carry a valid XSRF token in the POST or multipart form body and, if
+// it has an Origin or Referer header, that header must match the request's
+// host or an AllowedOrigins entry.
+func (p *Plugin) Before(w safehttp.ResponseWriter, r *safehttp.IncomingRequest) safehttp.Result {
+	setXCTO, err := w.Header().Claim("X-Content-Type-Options")
+	if err != nil {
+		return w.ServerError(safehttp.StatusInternalServerError)
+	}
+	setXCTO([]string{"nosniff"})
+
+	if isSafeMethod(r.Method) || p.exempt(r) {
+		return safehttp.Result{}
+	}
+
+	if !p.originAllowed(r) {
+		return w.ClientError(safehttp.StatusForbidden)
+	}
+
+	tok := r.PostFormValue(TokenKey)
+	if tok == "" {
+		return w.ClientError(safehttp.StatusUnauthorized)
+	}
+
+	userID, err := p.storage.GetUserID()
+	if err != nil {
+		return w.ServerError(safehttp.StatusInternalServerError)
+	}
+
+	valid, err := p.validToken(tok, r.Host, r.URL.Path, userID)
+	if err == errExpiredToken {
+		return w.ClientError(safehttp.StatusUnauthorized)
+	}
+	if err != nil || !valid {
+		return w.ClientError(safehttp.StatusForbidden)
+	}
+	return safehttp.Result{}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsrf
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeUserIDStorage struct{}
+
+func (fakeUserIDStorage) GetUserID() (string, error) {
+	return "potato", nil
+}
+
+// withNow overrides the package's clock for the duration of the test.
+func withNow(t *testing.T, ts time.Time) {
+	t.Helper()
+	old := now
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = old })
+}
+
+func TestTokenExpiry(t *testing.T) {
+	issuedAt := time.Unix(1000, 0)
+	withNow(t, issuedAt)
+	p := NewPlugin("1234", fakeUserIDStorage{}, TokenTTL(time.Minute))
+	tok, err := p.GenerateToken("foo.com", "/pizza")
+	if err != nil {
+		t.Fatalf("p.GenerateToken: got %v, want nil", err)
+	}
+
+	withNow(t, issuedAt.Add(30*time.Second))
+	if valid, err := p.validToken(tok, "foo.com", "/pizza", "potato"); err != nil || !valid {
+		t.Errorf("validToken before TTL: got (%v, %v), want (true, nil)", valid, err)
+	}
+
+	withNow(t, issuedAt.Add(2*time.Minute))
+	if valid, err := p.validToken(tok, "foo.com", "/pizza", "potato"); err != errExpiredToken || valid {
+		t.Errorf("validToken after TTL: got (%v, %v), want (false, %v)", valid, err, errExpiredToken)
+	}
+}
+
+func TestTokenClockSkew(t *testing.T) {
+	issuedAt := time.Unix(100000, 0)
+	withNow(t, issuedAt)
+	p := NewPlugin("1234", fakeUserIDStorage{})
+	tok, err := p.GenerateToken("foo.com", "/pizza")
+	if err != nil {
+		t.Fatalf("p.GenerateToken: got %v, want nil", err)
+	}
+
+	// The validating host's clock is slightly behind the issuing host's.
+	withNow(t, issuedAt.Add(-30*time.Second))
+	if valid, err := p.validToken(tok, "foo.com", "/pizza", "potato"); err != nil || !valid {
+		t.Errorf("validToken within allowed skew: got (%v, %v), want (true, nil)", valid, err)
+	}
+
+	withNow(t, issuedAt.Add(-2*time.Minute))
+	if valid, err := p.validToken(tok, "foo.com", "/pizza", "potato"); err != errExpiredToken || valid {
+		t.Errorf("validToken beyond allowed skew: got (%v, %v), want (false, %v)", valid, err, errExpiredToken)
+	}
+}
@@ -225,6 +225,140 @@ func TestXSRFTokenMultipart(t *testing.T) {
 	}
 }
 
+func TestXSRFTokenRotatedSecret(t *testing.T) {
+	old := xsrf.NewPlugin("old-secret", testUserIDStorage{})
+	tok, err := old.GenerateToken("foo.com", "/pizza")
+	if err != nil {
+		t.Fatalf("old.GenerateToken: got %v, want nil", err)
+	}
+
+	// The HMAC key was rotated, but the previous secret is still accepted
+	// so tokens issued before the rotation keep working.
+	rotated := xsrf.NewPlugin("new-secret", testUserIDStorage{}, xsrf.PreviousSecrets("old-secret"))
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", strings.NewReader(xsrf.TokenKey+"="+tok))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := safehttp.NewMachinery(rotated.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+
+	// A secret that was never configured is rejected.
+	unrelated := xsrf.NewPlugin("new-secret", testUserIDStorage{}, xsrf.PreviousSecrets("some-other-secret"))
+	req2 := httptest.NewRequest("POST", "http://foo.com/pizza", strings.NewReader(xsrf.TokenKey+"="+tok))
+	req2.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m2 := safehttp.NewMachinery(unrelated.Before, &testDispatcher{})
+	b2 := strings.Builder{}
+	rec2 := newResponseRecorder(&b2)
+	m2.HandleRequest(rec2, req2)
+	if rec2.status != http.StatusForbidden {
+		t.Errorf("response status: got %v, want %v", rec2.status, http.StatusForbidden)
+	}
+}
+
+func TestXSRFSafeMethodsPassWithoutToken(t *testing.T) {
+	for _, method := range []string{"GET", "HEAD", "OPTIONS", "TRACE"} {
+		p := xsrf.NewPlugin("1234", testUserIDStorage{})
+		req := httptest.NewRequest(method, "http://foo.com/pizza", nil)
+		m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+		b := strings.Builder{}
+		rec := newResponseRecorder(&b)
+		m.HandleRequest(rec, req)
+		if rec.status != http.StatusOK {
+			t.Errorf("%s response status: got %v, want %v", method, rec.status, http.StatusOK)
+		}
+	}
+}
+
+func TestXSRFExemptPathPassesWithoutToken(t *testing.T) {
+	p := xsrf.NewPlugin("1234", testUserIDStorage{})
+	p.Exempt("POST", "/webhooks/*")
+	req := httptest.NewRequest("POST", "http://foo.com/webhooks/stripe", strings.NewReader("foo=bar"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+}
+
+func TestXSRFExemptFuncPassesWithoutToken(t *testing.T) {
+	p := xsrf.NewPlugin("1234", testUserIDStorage{})
+	p.ExemptFunc(func(r *safehttp.IncomingRequest) bool {
+		return r.URL.Path == "/oauth/callback"
+	})
+	req := httptest.NewRequest("POST", "http://foo.com/oauth/callback", strings.NewReader("foo=bar"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+}
+
+func TestXSRFOriginMismatchRejectedRegardlessOfToken(t *testing.T) {
+	p := xsrf.NewPlugin("1234", testUserIDStorage{})
+	tok, err := p.GenerateToken("foo.com", "/pizza")
+	if err != nil {
+		t.Fatalf("p.GenerateToken: got %v, want nil", err)
+	}
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", strings.NewReader(xsrf.TokenKey+"="+tok))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://evil.com")
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusForbidden {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusForbidden)
+	}
+}
+
+func TestXSRFOriginAllowlisted(t *testing.T) {
+	p := xsrf.NewPlugin("1234", testUserIDStorage{}, xsrf.AllowedOrigins("partner.com"))
+	tok, err := p.GenerateToken("foo.com", "/pizza")
+	if err != nil {
+		t.Fatalf("p.GenerateToken: got %v, want nil", err)
+	}
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", strings.NewReader(xsrf.TokenKey+"="+tok))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://partner.com")
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusOK {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusOK)
+	}
+}
+
+func TestXSRFOriginNullRejected(t *testing.T) {
+	p := xsrf.NewPlugin("1234", testUserIDStorage{})
+	tok, err := p.GenerateToken("foo.com", "/pizza")
+	if err != nil {
+		t.Fatalf("p.GenerateToken: got %v, want nil", err)
+	}
+	req := httptest.NewRequest("POST", "http://foo.com/pizza", strings.NewReader(xsrf.TokenKey+"="+tok))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Browsers send the literal "null" Origin for opaque-origin requests,
+	// e.g. from a sandboxed iframe or a data: URL: a classic cross-origin
+	// POST bypass vector. It must not be treated as if no header was sent.
+	req.Header.Set("Origin", "null")
+	m := safehttp.NewMachinery(p.Before, &testDispatcher{})
+	b := strings.Builder{}
+	rec := newResponseRecorder(&b)
+	m.HandleRequest(rec, req)
+	if rec.status != http.StatusForbidden {
+		t.Errorf("response status: got %v, want %v", rec.status, http.StatusForbidden)
+	}
+}
+
 func TestXSRFMissingToken(t *testing.T) {
 	tests := []struct {
 		name       string